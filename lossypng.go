@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"image"
@@ -15,10 +16,11 @@ import (
 	"sync"
 
 	"github.com/foobaz/lossypng/lossypng"
+	"github.com/foobaz/lossypng/orientation"
 )
 
 func main() {
-	var convertToRGBA, convertToGrayscale, rewriteOriginal bool
+	var convertToRGBA, convertToGrayscale, rewriteOriginal, orient bool
 	var quantization int
 	var extension, addProcessing string
 	flag.BoolVar(&rewriteOriginal, "r", false, "rewrite original")
@@ -27,6 +29,7 @@ func main() {
 	flag.IntVar(&quantization, "s", 20, "quantization threshold, zero is lossless")
 	flag.StringVar(&extension, "e", "-lossy.png", "filename extension of output files")
 	flag.StringVar(&addProcessing, "a", "", "external command after fail")
+	flag.BoolVar(&orient, "orient", true, "normalize orientation using EXIF metadata before compressing")
 	flag.Parse()
 
 	var colorConversion lossypng.ColorConversion
@@ -48,8 +51,9 @@ func main() {
 	pathChan := make(chan string)
 	var waiter sync.WaitGroup
 	waiter.Add(n)
+	bufferPool := new(encoderBufferPool)
 	for i := 0; i < n; i++ {
-		go optimizePaths(pathChan, &waiter, colorConversion, quantization, extension, rewriteOriginal, addProcessing)
+		go optimizePaths(pathChan, &waiter, bufferPool, colorConversion, quantization, extension, rewriteOriginal, orient, addProcessing)
 	}
 	for _, path := range flag.Args() {
 		pathChan <- path
@@ -61,42 +65,50 @@ func main() {
 func optimizePaths(
 	pathChan <-chan string,
 	waiter *sync.WaitGroup,
+	bufferPool png.EncoderBufferPool,
 	colorConversion lossypng.ColorConversion,
 	quantization int,
 	extension string,
-	rewriteOriginal bool,
+	rewriteOriginal, orient bool,
 	addProcessing string,
 ) {
+	encoder := &lossypng.Encoder{
+		Quantization:    quantization,
+		ColorConversion: colorConversion,
+	}
+	encoder.BufferPool = bufferPool
 	for path := range pathChan {
-		optimizePath(path, colorConversion, quantization, extension, rewriteOriginal, addProcessing)
+		optimizePath(path, encoder, extension, rewriteOriginal, orient, addProcessing)
 	}
 	waiter.Done()
 }
 
 func optimizePath(
 	inPath string,
-	colorConversion lossypng.ColorConversion,
-	quantization int,
+	encoder *lossypng.Encoder,
 	extension string,
-	rewriteOriginal bool,
+	rewriteOriginal, orient bool,
 	addProcessing string,
 ) {
 	// load image
-	inFile, openErr := os.Open(inPath)
-	if openErr != nil {
-		fmt.Printf("couldn't open %v: %v\n", inPath, openErr)
+	inBytes, readErr := os.ReadFile(inPath)
+	if readErr != nil {
+		fmt.Printf("couldn't open %v: %v\n", inPath, readErr)
 		return
 	}
 
-	inInfo, inStatErr := inFile.Stat()
-	decoded, _, decodeErr := image.Decode(inFile)
-	inFile.Close()
+	inSize := int64(len(inBytes))
+	decoded, _, decodeErr := image.Decode(bytes.NewReader(inBytes))
 	if decodeErr != nil {
 		fmt.Printf("couldn't decode %v: %v\n", inPath, decodeErr)
 		return
 	}
 
-	optimized := lossypng.Compress(decoded, colorConversion, quantization)
+	if orient {
+		if o := orientation.Read(inBytes); o != orientation.Unspecified {
+			decoded = orientation.Apply(decoded, o)
+		}
+	}
 
 	// save optimized image
 	outPath := pathWithSuffix(inPath, extension)
@@ -106,7 +118,7 @@ func optimizePath(
 		return
 	}
 
-	encodeErr := png.Encode(outFile, optimized)
+	encodeErr := encoder.Encode(outFile, decoded)
 	outInfo, outStatErr := outFile.Stat()
 	outFile.Close()
 	if encodeErr != nil {
@@ -115,22 +127,16 @@ func optimizePath(
 	}
 
 	// print compression statistics
-	var intPercent int64
-	var inSize, outSize int64
-	var inSizeDesc, outSizeDesc, percentage string
-	if inStatErr != nil {
-		inSizeDesc = "???B"
-	} else {
-		inSize = inInfo.Size()
-		inSizeDesc = sizeDesc(inSize)
-	}
+	var intPercent, outSize int64
+	inSizeDesc := sizeDesc(inSize)
+	var outSizeDesc, percentage string
 	if outStatErr != nil {
 		outSizeDesc = "???B"
 	} else {
 		outSize = outInfo.Size()
 		outSizeDesc = sizeDesc(outSize)
 	}
-	if inStatErr != nil || outStatErr != nil {
+	if outStatErr != nil {
 		percentage = "???%"
 	} else {
 		intPercent = (outSize*100 + inSize/2) / inSize
@@ -172,6 +178,22 @@ func optimizePath(
 	)
 }
 
+// encoderBufferPool lets the worker goroutines spawned by optimizePaths
+// share zlib and filter buffers across calls to (*lossypng.Encoder).Encode
+// instead of each allocating its own.
+type encoderBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *encoderBufferPool) Get() *png.EncoderBuffer {
+	buffer, _ := p.pool.Get().(*png.EncoderBuffer)
+	return buffer
+}
+
+func (p *encoderBufferPool) Put(buffer *png.EncoderBuffer) {
+	p.pool.Put(buffer)
+}
+
 func pathWithSuffix(filePath string, suffix string) string {
 	extension := path.Ext(filePath)
 	insertion := len(extension)
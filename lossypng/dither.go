@@ -0,0 +1,146 @@
+package lossypng
+
+// Ditherer diffuses the rounding error introduced by quantization into
+// neighboring pixels, trading sharp banding for less objectionable noise.
+// Built-in kernels are Sierra (the long-standing default), FloydSteinberg,
+// Atkinson, Jarvis, Stucki, and NoDither.
+type Ditherer interface {
+	// Diffuse combines error already accumulated from previously processed
+	// pixels into the error that should be applied at column x of the row
+	// currently being filled. errorRows holds Rows()+1 row buffers, each
+	// padded by Padding() colorDeltas on either side: errorRows[0] is the
+	// row currently being filled, populated for every column left of x,
+	// and errorRows[1:] are the Rows() most recently completed rows.
+	Diffuse(errorRows [][]colorDelta, x int) colorDelta
+
+	// Rows reports how many rows of completed history this kernel reads
+	// from, in addition to the row currently being filled.
+	Rows() int
+
+	// Padding reports how many colorDeltas of horizontal padding this
+	// kernel needs on each side of a row.
+	Padding() int
+}
+
+// ditherWeight contributes weight/matrixDitherer.divisor of the error from
+// the pixel `dx` columns to the left (negative dx: to the right), `row`
+// rows ago (0 is the row currently being filled), into the pixel
+// currently being diffused.
+type ditherWeight struct {
+	row    int
+	dx     int
+	weight int32
+}
+
+// matrixDitherer implements Ditherer for a fixed error-diffusion matrix,
+// expressed as the classic "weight over divisor" fractions used to
+// describe kernels like Floyd-Steinberg and Sierra.
+type matrixDitherer struct {
+	rows    int
+	padding int
+	divisor int32
+	weights []ditherWeight
+}
+
+func (d *matrixDitherer) Rows() int    { return d.rows }
+func (d *matrixDitherer) Padding() int { return d.padding }
+
+func (d *matrixDitherer) Diffuse(errorRows [][]colorDelta, x int) colorDelta {
+	var delta colorDelta
+	for _, w := range d.weights {
+		source := errorRows[w.row][x-w.dx]
+		for i := 0; i < deltaComponents; i++ {
+			delta[i] += w.weight * source[i]
+		}
+	}
+	for i := 0; i < deltaComponents; i++ {
+		if delta[i] < 0 {
+			delta[i] -= d.divisor / 2
+		} else {
+			delta[i] += d.divisor / 2
+		}
+		delta[i] /= d.divisor
+	}
+	return delta
+}
+
+// noDitherer implements Ditherer by discarding quantization error instead
+// of diffusing it, for callers who want pure quantization.
+type noDitherer struct{}
+
+func (noDitherer) Diffuse([][]colorDelta, int) colorDelta { return colorDelta{} }
+func (noDitherer) Rows() int                              { return 0 }
+func (noDitherer) Padding() int                           { return 0 }
+
+var (
+	// Sierra is the two-row Sierra error-diffusion kernel that this
+	// package has always used.
+	Sierra Ditherer = &matrixDitherer{
+		rows:    2,
+		padding: 2,
+		divisor: 32,
+		weights: []ditherWeight{
+			{0, 1, 5}, {0, 2, 3},
+			{1, -2, 2}, {1, -1, 4}, {1, 0, 5}, {1, 1, 4}, {1, 2, 2},
+			{2, -1, 2}, {2, 0, 3}, {2, 1, 2},
+		},
+	}
+
+	// FloydSteinberg is the classic Floyd-Steinberg error-diffusion
+	// kernel. It has a narrower footprint than Sierra and produces
+	// sharper, more visible dithering patterns.
+	FloydSteinberg Ditherer = &matrixDitherer{
+		rows:    1,
+		padding: 1,
+		divisor: 16,
+		weights: []ditherWeight{
+			{0, 1, 7},
+			{1, -1, 3}, {1, 0, 5}, {1, 1, 1},
+		},
+	}
+
+	// Atkinson is Bill Atkinson's error-diffusion kernel. It only
+	// diffuses 75% of each pixel's error, which keeps flat areas cleaner
+	// at the cost of losing some contrast in dark and light regions.
+	Atkinson Ditherer = &matrixDitherer{
+		rows:    2,
+		padding: 2,
+		divisor: 8,
+		weights: []ditherWeight{
+			{0, 1, 1}, {0, 2, 1},
+			{1, -1, 1}, {1, 0, 1}, {1, 1, 1},
+			{2, 0, 1},
+		},
+	}
+
+	// Jarvis is the Jarvis-Judice-Ninke error-diffusion kernel. Its wider
+	// footprint produces smoother gradients than Sierra at extra cost.
+	Jarvis Ditherer = &matrixDitherer{
+		rows:    2,
+		padding: 2,
+		divisor: 48,
+		weights: []ditherWeight{
+			{0, 1, 7}, {0, 2, 5},
+			{1, -2, 3}, {1, -1, 5}, {1, 0, 7}, {1, 1, 5}, {1, 2, 3},
+			{2, -2, 1}, {2, -1, 3}, {2, 0, 5}, {2, 1, 3}, {2, 2, 1},
+		},
+	}
+
+	// Stucki is the Stucki error-diffusion kernel, a sharper variant of
+	// Jarvis with the same footprint.
+	Stucki Ditherer = &matrixDitherer{
+		rows:    2,
+		padding: 2,
+		divisor: 42,
+		weights: []ditherWeight{
+			{0, 1, 8}, {0, 2, 4},
+			{1, -2, 2}, {1, -1, 4}, {1, 0, 8}, {1, 1, 4}, {1, 2, 2},
+			{2, -2, 1}, {2, -1, 2}, {2, 0, 4}, {2, 1, 2}, {2, 2, 1},
+		},
+	}
+
+	// NoDither disables error diffusion entirely, producing pure
+	// quantization. This is useful when feeding the output into another
+	// lossy stage that would otherwise amplify the diffused noise.
+	NoDither Ditherer = noDitherer{}
+)
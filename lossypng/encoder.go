@@ -0,0 +1,46 @@
+package lossypng
+
+import (
+	"image"
+	"image/png"
+	"io"
+)
+
+// Encoder lossily compresses an image.Image before encoding it as a PNG,
+// mirroring the API of image/png's Encoder so that callers can reuse its
+// CompressionLevel and BufferPool tuning across many calls instead of
+// re-allocating zlib and filter buffers for every image.
+type Encoder struct {
+	// Quantization determines the strength of the lossy compression, as in
+	// Compress. Zero means lossless.
+	Quantization int
+
+	// ColorConversion specifies what color profile the image should be
+	// converted to, if any, as in Compress.
+	ColorConversion ColorConversion
+
+	// Options customizes the compression beyond Quantization and
+	// ColorConversion, e.g. selecting a Ditherer.
+	Options Options
+
+	png.Encoder
+}
+
+// Encode lossily compresses m and writes it to w as a PNG, using the
+// Encoder's Quantization, ColorConversion, and Options settings. The
+// embedded png.Encoder's CompressionLevel and BufferPool are used to
+// perform the actual PNG encoding.
+func (enc *Encoder) Encode(w io.Writer, m image.Image) error {
+	if enc.Options.PreProcess != nil {
+		m = enc.Options.PreProcess(m)
+	}
+	optimized := CompressOptions(m, enc.ColorConversion, enc.Quantization, enc.Options)
+	return enc.Encoder.Encode(w, optimized)
+}
+
+// Decode reads a PNG image from r. It is provided as a convenience
+// counterpart to Encode; lossy compression only affects the pixels written
+// by Encode, so decoding is identical to image/png's Decode.
+func Decode(r io.Reader) (image.Image, error) {
+	return png.Decode(r)
+}
@@ -0,0 +1,50 @@
+package lossypng
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncoderDecodeRoundTrip(t *testing.T) {
+	bounds := image.Rect(0, 0, 32, 32)
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), uint8((x + y) * 4), 255})
+		}
+	}
+
+	enc := &Encoder{Quantization: 20}
+	compressed := new(bytes.Buffer)
+	if err := enc.Encode(compressed, img); err != nil {
+		t.Fatalf("Encoder.Encode returned unexpected error: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode returned unexpected error: %v", err)
+	}
+	if decoded.Bounds() != bounds {
+		t.Fatalf("Decode changed image bounds: got %v, want %v", decoded.Bounds(), bounds)
+	}
+}
+
+func TestEncoderPreProcess(t *testing.T) {
+	bounds := image.Rect(0, 0, 4, 4)
+	img := image.NewRGBA(bounds)
+
+	called := false
+	enc := &Encoder{Options: Options{PreProcess: func(m image.Image) image.Image {
+		called = true
+		return m
+	}}}
+
+	if err := enc.Encode(new(bytes.Buffer), img); err != nil {
+		t.Fatalf("Encoder.Encode returned unexpected error: %v", err)
+	}
+	if !called {
+		t.Fatalf("Encoder.Encode did not invoke Options.PreProcess")
+	}
+}
@@ -21,10 +21,64 @@ const (
 
 	// RGBAConversion specifies the image should be converted to RGBA
 	RGBAConversion
+
+	// PalettedConversion specifies the image should be quantized to an
+	// image.Paletted using a generated palette (see Options.MaxPaletteSize),
+	// then compressed with the Paeth-filter optimizer used for images
+	// that already decode as paletted.
+	PalettedConversion
 )
 
 const deltaComponents = 4
 
+// Options customizes how Compress lossily compresses an image, beyond the
+// quantization strength and color conversion it already accepts.
+type Options struct {
+	// Ditherer selects the error-diffusion kernel used while quantizing.
+	// The zero value selects Sierra, matching Compress's long-standing
+	// behavior.
+	Ditherer Ditherer
+
+	// PreProcess, if set, is run on the image before color conversion and
+	// quantization. It is only consulted by Encoder.Encode, letting
+	// callers plug in their own transforms (resize, rotate, strip-alpha)
+	// ahead of the lossy pipeline.
+	PreProcess func(image.Image) image.Image
+
+	// MaxPaletteSize caps how many entries PalettedConversion (or the
+	// AutoPalette heuristic) generates. The zero value means 256, the
+	// largest palette a PNG can hold.
+	MaxPaletteSize int
+
+	// AutoPalette switches CompressOptions to the paletted path when
+	// ColorConversion is NoConversion, the image isn't already paletted,
+	// and its unique color count falls at or below autoPaletteThreshold.
+	// It has no effect with GrayscaleConversion or RGBAConversion.
+	AutoPalette bool
+}
+
+func (o Options) ditherer() Ditherer {
+	if o.Ditherer == nil {
+		return Sierra
+	}
+	return o.Ditherer
+}
+
+func (o Options) maxPaletteSize() int {
+	switch {
+	case o.MaxPaletteSize <= 0:
+		return 256
+	case o.MaxPaletteSize > 256:
+		return 256
+	default:
+		return o.MaxPaletteSize
+	}
+}
+
+// autoPaletteThreshold is the unique-color count at or below which
+// Options.AutoPalette switches to the paletted compression path.
+const autoPaletteThreshold = 4096
+
 // Compress lossyly compresses a PNG image and optionaly converts the colorspace
 // of the output image. Quantization determines the strength of the compression.
 // Must be >= 0 .
@@ -33,6 +87,32 @@ func Compress(
 	colorConversion ColorConversion,
 	quantization int,
 ) image.Image {
+	return CompressOptions(decoded, colorConversion, quantization, Options{})
+}
+
+// CompressOptions behaves like Compress but lets the caller customize the
+// compression through opts, e.g. to choose a different Ditherer.
+func CompressOptions(
+	decoded image.Image,
+	colorConversion ColorConversion,
+	quantization int,
+	opts Options,
+) image.Image {
+	ditherer := opts.ditherer()
+
+	// autoHistogram holds the color histogram AutoPalette already paid to
+	// compute while deciding whether to switch to PalettedConversion, so
+	// that path doesn't scan the image a second time to build it.
+	var autoHistogram map[medianCutColor]int
+	if colorConversion == NoConversion && opts.AutoPalette {
+		if _, alreadyPaletted := decoded.(*image.Paletted); !alreadyPaletted {
+			if histogram, ok := uniqueColorHistogram(decoded, autoPaletteThreshold); ok {
+				colorConversion = PalettedConversion
+				autoHistogram = histogram
+			}
+		}
+	}
+
 	// optimize image, converting colorspace if requested
 	bounds := decoded.Bounds()
 	optimized := decoded // update optimized variable later if color conversion is necessary
@@ -40,35 +120,45 @@ func Compress(
 	case GrayscaleConversion:
 		converted := image.NewGray(bounds)
 		draw.Draw(converted, bounds, decoded, image.ZP, draw.Src)
-		optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 1, quantization)
+		optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 1, quantization, ditherer)
+		optimized = converted
+	case PalettedConversion:
+		histogram := autoHistogram
+		if histogram == nil {
+			histogram = buildColorHistogram(decoded)
+		}
+		palette := paletteFromHistogram(histogram, opts.maxPaletteSize())
+		converted := image.NewPaletted(bounds, palette)
+		draw.Draw(converted, bounds, decoded, image.ZP, draw.Src)
+		optimizeForPaethFilter(converted.Pix, bounds, converted.Stride, quantization, palette, ditherer)
 		optimized = converted
 	case RGBAConversion:
 		converted := image.NewRGBA(bounds)
 		draw.Draw(converted, bounds, decoded, image.ZP, draw.Src)
-		optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 4, quantization)
+		optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 4, quantization, ditherer)
 		optimized = converted
 	default:
 		// no color conversion requested
 		switch optimizee := decoded.(type) {
 		case *image.Alpha:
-			optimizeForAverageFilter(optimizee.Pix, bounds, optimizee.Stride, 1, quantization)
+			optimizeForAverageFilter(optimizee.Pix, bounds, optimizee.Stride, 1, quantization, ditherer)
 		case *image.Gray:
-			optimizeForAverageFilter(optimizee.Pix, bounds, optimizee.Stride, 1, quantization)
+			optimizeForAverageFilter(optimizee.Pix, bounds, optimizee.Stride, 1, quantization, ditherer)
 		case *image.NRGBA:
-			optimizeForAverageFilter(optimizee.Pix, bounds, optimizee.Stride, 4, quantization)
+			optimizeForAverageFilter(optimizee.Pix, bounds, optimizee.Stride, 4, quantization, ditherer)
 		case *image.Paletted:
 			// many PNGs decode as image.Paletted
 			// use alternative paeth optimizer for paletted images
-			optimizeForPaethFilter(optimizee.Pix, bounds, optimizee.Stride, quantization, optimizee.Palette)
+			optimizeForPaethFilter(optimizee.Pix, bounds, optimizee.Stride, quantization, optimizee.Palette, ditherer)
 		case *image.Alpha16:
 			converted := image.NewAlpha(bounds)
 			draw.Draw(converted, bounds, decoded, image.ZP, draw.Src)
-			optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 1, quantization)
+			optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 1, quantization, ditherer)
 			optimized = converted
 		case *image.Gray16:
 			converted := image.NewGray(bounds)
 			draw.Draw(converted, bounds, decoded, image.ZP, draw.Src)
-			optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 1, quantization)
+			optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 1, quantization, ditherer)
 			optimized = converted
 		default:
 			// convert all other formats to RGBA
@@ -76,7 +166,7 @@ func Compress(
 			// most PNGs decode as image.RGBA
 			converted := image.NewNRGBA(bounds)
 			draw.Draw(converted, bounds, decoded, image.ZP, draw.Src)
-			optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 4, quantization)
+			optimizeForAverageFilter(converted.Pix, bounds, converted.Stride, 4, quantization, ditherer)
 			optimized = converted
 		}
 	}
@@ -89,6 +179,7 @@ func optimizeForAverageFilter(
 	bounds image.Rectangle,
 	stride, bytesPerPixel int,
 	quantization int,
+	ditherer Ditherer,
 ) {
 	if quantization == 0 {
 		// Algorithm requires positive number.
@@ -100,17 +191,17 @@ func optimizeForAverageFilter(
 	height := bounds.Dy()
 	width := bounds.Dx()
 
-	const errorRowCount = 3
-	const filterWidth = 5
-	const filterCenter = 2
-	var colorError [errorRowCount][]colorDelta
-	for i := 0; i < errorRowCount; i++ {
+	errorRowCount := ditherer.Rows() + 1
+	filterWidth := 2*ditherer.Padding() + 1
+	filterCenter := ditherer.Padding()
+	colorError := make([][]colorDelta, errorRowCount)
+	for i := range colorError {
 		colorError[i] = make([]colorDelta, width+filterWidth-1)
 	}
 
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			diffusion := diffuseColorDeltas(colorError, x+filterCenter)
+			diffusion := ditherer.Diffuse(colorError, x+filterCenter)
 			for c := 0; c < bytesPerPixel; c++ {
 				offset := y*stride + x*bytesPerPixel + c
 				here := int32(pixels[offset])
@@ -137,9 +228,11 @@ func optimizeForAverageFilter(
 				colorError[0][x+filterCenter][c] = errorHere
 			}
 		}
-		for i := 0; i < errorRowCount; i++ {
-			colorError[(i+1)%errorRowCount] = colorError[i]
+		last := colorError[errorRowCount-1]
+		for i := errorRowCount - 1; i > 0; i-- {
+			colorError[i] = colorError[i-1]
 		}
+		colorError[0] = last
 	}
 }
 
@@ -149,6 +242,7 @@ func optimizeForPaethFilter(
 	stride int,
 	quantization int,
 	palette color.Palette,
+	ditherer Ditherer,
 ) {
 	colorCount := len(palette)
 	if colorCount <= 0 {
@@ -158,17 +252,17 @@ func optimizeForPaethFilter(
 	height := bounds.Dy()
 	width := bounds.Dx()
 
-	const errorRowCount = 3
-	const filterWidth = 5
-	const filterCenter = 2
-	var colorError [errorRowCount][]colorDelta
-	for i := 0; i < errorRowCount; i++ {
+	errorRowCount := ditherer.Rows() + 1
+	filterWidth := 2*ditherer.Padding() + 1
+	filterCenter := ditherer.Padding()
+	colorError := make([][]colorDelta, errorRowCount)
+	for i := range colorError {
 		colorError[i] = make([]colorDelta, width+filterWidth-1)
 	}
 
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			diffusion := diffuseColorDeltas(colorError, x+filterCenter)
+			diffusion := ditherer.Diffuse(colorError, x+filterCenter)
 
 			offset := y*stride + x
 			here := pixels[offset]
@@ -207,9 +301,11 @@ func optimizeForPaethFilter(
 			pixels[offset] = bestColor
 			colorError[0][x+filterCenter] = bestDelta
 		}
-		for i := 0; i < errorRowCount; i++ {
-			colorError[(i+1)%errorRowCount] = colorError[i]
+		last := colorError[errorRowCount-1]
+		for i := errorRowCount - 1; i > 0; i-- {
+			colorError[i] = colorError[i-1]
 		}
+		colorError[0] = last
 	}
 }
 
@@ -294,30 +390,6 @@ func (a colorDelta) add(b colorDelta) colorDelta {
 	return delta
 }
 
-func diffuseColorDeltas(colorError [3][]colorDelta, x int) colorDelta {
-	var delta colorDelta
-	// Sierra dithering
-	for i := 0; i < deltaComponents; i++ {
-		delta[i] += 2 * colorError[2][x-1][i]
-		delta[i] += 3 * colorError[2][x][i]
-		delta[i] += 2 * colorError[2][x+1][i]
-		delta[i] += 2 * colorError[1][x-2][i]
-		delta[i] += 4 * colorError[1][x-1][i]
-		delta[i] += 5 * colorError[1][x][i]
-		delta[i] += 4 * colorError[1][x+1][i]
-		delta[i] += 2 * colorError[1][x+2][i]
-		delta[i] += 3 * colorError[0][x-2][i]
-		delta[i] += 5 * colorError[0][x-1][i]
-		if delta[i] < 0 {
-			delta[i] -= 16
-		} else {
-			delta[i] += 16
-		}
-		delta[i] /= 32
-	}
-	return delta
-}
-
 func abs(x int) int {
 	if x < 0 {
 		return -x
@@ -3,6 +3,7 @@ package lossypng
 import (
 	"bytes"
 	"image"
+	"image/color"
 	"image/png"
 	"os"
 	"testing"
@@ -34,7 +35,7 @@ func TestCompression(t *testing.T) {
 			buf := new(bytes.Buffer)
 			err := png.Encode(buf, compressed)
 			if err != nil {
-				t.Fatalf("couldn't encode file %d", name)
+				t.Fatalf("couldn't encode file %s", name)
 			}
 			if int64(buf.Len()) >= originalSize {
 				t.Fatalf("sample %s did not compress in mode %d", name, mode)
@@ -42,3 +43,103 @@ func TestCompression(t *testing.T) {
 		}
 	}
 }
+
+func TestDitherers(t *testing.T) {
+	ditherers := map[string]Ditherer{
+		"Sierra":         Sierra,
+		"FloydSteinberg": FloydSteinberg,
+		"Atkinson":       Atkinson,
+		"Jarvis":         Jarvis,
+		"Stucki":         Stucki,
+		"NoDither":       NoDither,
+	}
+	for _, name := range [...]string{"sample.png", "paletted.png"} {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("couldn't open file %s", name)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatalf("couldn't read file size of %s", name)
+		}
+		originalSize := info.Size()
+		defer file.Close()
+		img, _, err := image.Decode(file)
+		if err != nil {
+			t.Fatalf("couldn't decode file %s", name)
+		}
+
+		for ditherName, ditherer := range ditherers {
+			compressed := CompressOptions(img, NoConversion, 20, Options{Ditherer: ditherer})
+			buf := new(bytes.Buffer)
+			if err := png.Encode(buf, compressed); err != nil {
+				t.Fatalf("couldn't encode file %s with ditherer %s", name, ditherName)
+			}
+			if int64(buf.Len()) >= originalSize {
+				t.Fatalf("sample %s did not compress with ditherer %s", name, ditherName)
+			}
+		}
+	}
+}
+
+func TestCompressToQuality(t *testing.T) {
+	bounds := image.Rect(0, 0, 16, 16)
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), uint8((x + y) * 8), 255})
+		}
+	}
+
+	compressed, err := CompressToQuality(img, MinSSIM(0.9))
+	if err != nil {
+		t.Fatalf("CompressToQuality returned unexpected error: %v", err)
+	}
+	if compressed.Bounds() != bounds {
+		t.Fatalf("CompressToQuality changed image bounds: got %v, want %v", compressed.Bounds(), bounds)
+	}
+
+	_, err = CompressToQuality(img, MinSSIM(2))
+	if err != ErrQualityUnreachable {
+		t.Fatalf("expected ErrQualityUnreachable for an impossible target, got %v", err)
+	}
+}
+
+func TestPalettedConversion(t *testing.T) {
+	bounds := image.Rect(0, 0, 32, 32)
+	img := image.NewRGBA(bounds)
+	palette := []color.RGBA{
+		{255, 0, 0, 255},
+		{0, 255, 0, 255},
+		{0, 0, 255, 255},
+		{255, 255, 255, 255},
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.Set(x, y, palette[(x+y)%len(palette)])
+		}
+	}
+
+	compressed := CompressOptions(img, PalettedConversion, 10, Options{})
+	paletted, ok := compressed.(*image.Paletted)
+	if !ok {
+		t.Fatalf("PalettedConversion produced %T, want *image.Paletted", compressed)
+	}
+	if len(paletted.Palette) > 256 {
+		t.Fatalf("palette has %d entries, want at most 256", len(paletted.Palette))
+	}
+
+	capped := CompressOptions(img, PalettedConversion, 10, Options{MaxPaletteSize: 2})
+	cappedPaletted, ok := capped.(*image.Paletted)
+	if !ok {
+		t.Fatalf("capped PalettedConversion produced %T, want *image.Paletted", capped)
+	}
+	if len(cappedPaletted.Palette) > 2 {
+		t.Fatalf("capped palette has %d entries, want at most 2", len(cappedPaletted.Palette))
+	}
+
+	autoConverted := CompressOptions(img, NoConversion, 10, Options{AutoPalette: true})
+	if _, ok := autoConverted.(*image.Paletted); !ok {
+		t.Fatalf("AutoPalette produced %T, want *image.Paletted for a low-color image", autoConverted)
+	}
+}
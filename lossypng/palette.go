@@ -0,0 +1,193 @@
+package lossypng
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// medianCutColor is a distinct color gathered from the source image while
+// building a palette: 8-bit, non-premultiplied RGBA.
+type medianCutColor struct {
+	r, g, b, a uint8
+}
+
+func (c medianCutColor) channel(i int) uint8 {
+	switch i {
+	case 0:
+		return c.r
+	case 1:
+		return c.g
+	case 2:
+		return c.b
+	default:
+		return c.a
+	}
+}
+
+// weightedColor is one distinct color and the number of pixels it covers,
+// the unit median cut operates on so that a handful of outlier pixels
+// can't outweigh a populous color region.
+type weightedColor struct {
+	color medianCutColor
+	count int
+}
+
+// medianCutBox is one region of color space in the median cut algorithm,
+// holding every distinct color currently assigned to it.
+type medianCutBox struct {
+	colors []weightedColor
+	total  int // sum of colors[*].count, i.e. pixels covered by this box
+}
+
+// widestChannel reports which of the box's four channels spans the
+// largest range, and that range.
+func (b medianCutBox) widestChannel() (channel int, channelRange int) {
+	for c := 0; c < 4; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, wc := range b.colors {
+			v := wc.color.channel(c)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if r := int(hi) - int(lo); r > channelRange {
+			channel, channelRange = c, r
+		}
+	}
+	return
+}
+
+func (b medianCutBox) mean() medianCutColor {
+	var sum [4]int64
+	for _, wc := range b.colors {
+		weight := int64(wc.count)
+		sum[0] += int64(wc.color.r) * weight
+		sum[1] += int64(wc.color.g) * weight
+		sum[2] += int64(wc.color.b) * weight
+		sum[3] += int64(wc.color.a) * weight
+	}
+	n := int64(b.total)
+	return medianCutColor{
+		uint8(sum[0] / n),
+		uint8(sum[1] / n),
+		uint8(sum[2] / n),
+		uint8(sum[3] / n),
+	}
+}
+
+// split divides the box in two at the median population along its widest
+// channel: the first half takes the colors, in channel order, up to and
+// including the one at which the running pixel count reaches half of the
+// box's total, and the second half takes the rest (at least one color).
+func (b medianCutBox) split() (medianCutBox, medianCutBox) {
+	channel, _ := b.widestChannel()
+	sorted := make([]weightedColor, len(b.colors))
+	copy(sorted, b.colors)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].color.channel(channel) < sorted[j].color.channel(channel)
+	})
+
+	half := b.total / 2
+	cumulative, splitAt := 0, 0
+	for i, wc := range sorted {
+		cumulative += wc.count
+		splitAt = i + 1
+		if cumulative >= half {
+			break
+		}
+	}
+	if splitAt == len(sorted) {
+		splitAt = len(sorted) - 1
+	}
+
+	a := medianCutBox{colors: sorted[:splitAt]}
+	b2 := medianCutBox{colors: sorted[splitAt:]}
+	for _, wc := range a.colors {
+		a.total += wc.count
+	}
+	for _, wc := range b2.colors {
+		b2.total += wc.count
+	}
+	return a, b2
+}
+
+// paletteFromHistogram builds a color.Palette with at most maxColors
+// entries from histogram, using the median cut algorithm: repeatedly
+// split the box with the widest single-channel range along its longest
+// axis at the median population, until there are enough boxes, then emit
+// each box's population-weighted mean color as a palette entry.
+func paletteFromHistogram(histogram map[medianCutColor]int, maxColors int) color.Palette {
+	colors := make([]weightedColor, 0, len(histogram))
+	total := 0
+	for c, count := range histogram {
+		colors = append(colors, weightedColor{c, count})
+		total += count
+	}
+	if len(colors) == 0 {
+		return color.Palette{color.NRGBA{}}
+	}
+
+	boxes := []medianCutBox{{colors: colors, total: total}}
+	for len(boxes) < maxColors {
+		splitIndex, splitRange := -1, 0
+		for i, box := range boxes {
+			if len(box.colors) < 2 {
+				continue
+			}
+			if _, r := box.widestChannel(); r > splitRange {
+				splitIndex, splitRange = i, r
+			}
+		}
+		if splitIndex < 0 {
+			break
+		}
+
+		a, b := boxes[splitIndex].split()
+		boxes[splitIndex] = a
+		boxes = append(boxes, b)
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		mean := box.mean()
+		palette[i] = color.NRGBA{mean.r, mean.g, mean.b, mean.a}
+	}
+	return palette
+}
+
+// buildColorHistogram scans every pixel of img once, counting how many
+// pixels have each distinct 8-bit non-premultiplied RGBA color.
+func buildColorHistogram(img image.Image) map[medianCutColor]int {
+	bounds := img.Bounds()
+	histogram := make(map[medianCutColor]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			nrgba := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			histogram[medianCutColor{nrgba.R, nrgba.G, nrgba.B, nrgba.A}]++
+		}
+	}
+	return histogram
+}
+
+// uniqueColorHistogram scans img like buildColorHistogram, but stops early
+// and returns ok=false as soon as more than limit distinct colors have
+// been seen, so a high-color image can be rejected without scanning it in
+// full.
+func uniqueColorHistogram(img image.Image, limit int) (histogram map[medianCutColor]int, ok bool) {
+	bounds := img.Bounds()
+	histogram = make(map[medianCutColor]int, limit+1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			nrgba := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			histogram[medianCutColor{nrgba.R, nrgba.G, nrgba.B, nrgba.A}]++
+			if len(histogram) > limit {
+				return nil, false
+			}
+		}
+	}
+	return histogram, true
+}
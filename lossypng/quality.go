@@ -0,0 +1,199 @@
+package lossypng
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// Quality expresses a perceptual compression target for CompressToQuality,
+// such as MinSSIM(0.95) or MinPSNR(40).
+type Quality struct {
+	name    string
+	target  float64
+	max     int
+	measure func(original, candidate image.Image) float64
+}
+
+// defaultMaxQuantization is the upper bound CompressToQuality searches up
+// to unless overridden with Quality.Max.
+const defaultMaxQuantization = 80
+
+// MinSSIM requires the compressed image's SSIM (structural similarity,
+// 0..1, higher is better) against the original to be at least min.
+func MinSSIM(min float64) Quality {
+	return Quality{name: "SSIM", target: min, max: defaultMaxQuantization, measure: ssim}
+}
+
+// MinPSNR requires the compressed image's PSNR in decibels (higher is
+// better) against the original to be at least min.
+func MinPSNR(min float64) Quality {
+	return Quality{name: "PSNR", target: min, max: defaultMaxQuantization, measure: psnr}
+}
+
+// Max returns a copy of q with the quantization upper bound that
+// CompressToQuality searches up to changed from its default of 80.
+func (q Quality) Max(max int) Quality {
+	q.max = max
+	return q
+}
+
+// ErrQualityUnreachable is returned by CompressToQuality when even
+// quantization=1 fails to meet the requested Quality target. The image
+// returned alongside it is a lossless copy.
+var ErrQualityUnreachable = errors.New("lossypng: quality target not reachable at any quantization, returning lossless copy")
+
+// CompressToQuality searches for the largest quantization level, up to
+// target's max (80 by default), whose lossy compression still meets
+// target, minimizing file size subject to the requested quality. If even
+// quantization=1 fails target, it returns a losslessly-copied image
+// alongside ErrQualityUnreachable.
+func CompressToQuality(img image.Image, target Quality) (image.Image, error) {
+	hi := target.max
+	if hi < 1 {
+		hi = 1
+	}
+
+	lowest := Compress(img, NoConversion, 1)
+	if target.measure(img, lowest) < target.target {
+		return Compress(img, NoConversion, 0), ErrQualityUnreachable
+	}
+
+	best, lo := lowest, 1
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		candidate := Compress(img, NoConversion, mid)
+		if target.measure(img, candidate) >= target.target {
+			best, lo = candidate, mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return best, nil
+}
+
+// psnr computes the peak signal-to-noise ratio, in decibels, of candidate
+// against original, averaging squared error over every RGB sample.
+func psnr(original, candidate image.Image) float64 {
+	bounds := original.Bounds()
+	var sumSquaredError, sampleCount float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			or, og, ob, _ := original.At(x, y).RGBA()
+			cr, cg, cb, _ := candidate.At(x, y).RGBA()
+			sumSquaredError += channelSquaredError(or, cr)
+			sumSquaredError += channelSquaredError(og, cg)
+			sumSquaredError += channelSquaredError(ob, cb)
+			sampleCount += 3
+		}
+	}
+
+	meanSquaredError := sumSquaredError / sampleCount
+	if meanSquaredError == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/meanSquaredError)
+}
+
+func channelSquaredError(a, b uint32) float64 {
+	delta := float64(a>>8) - float64(b>>8)
+	return delta * delta
+}
+
+// ssimWindow is the side length, in pixels, of the sliding window SSIM is
+// averaged over.
+const ssimWindow = 8
+
+// ssimC1 and ssimC2 stabilize the SSIM ratio when window means or
+// variances are near zero, per the standard formulation with L=255.
+const (
+	ssimC1 = 0.01 * 255 * 0.01 * 255
+	ssimC2 = 0.03 * 255 * 0.03 * 255
+)
+
+// ssimChannelWeights combine per-channel SSIM into one score, weighted by
+// BT.601 luma coefficients.
+var ssimChannelWeights = [3]float64{0.299, 0.587, 0.114}
+
+// ssim computes the structural similarity of candidate against original,
+// using uniformly-weighted 8x8 windows averaged per channel, then combined
+// across channels by luma weight. The window slides one pixel at a time;
+// along a side shorter than 8 pixels a single window spans the whole side.
+func ssim(original, candidate image.Image) float64 {
+	bounds := original.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var weightedSum float64
+	var windowCount float64
+	for wy := 0; wy < height && (wy == 0 || wy+ssimWindow <= height); wy++ {
+		windowHeight := ssimWindow
+		if wy+windowHeight > height {
+			windowHeight = height - wy
+		}
+		for wx := 0; wx < width && (wx == 0 || wx+ssimWindow <= width); wx++ {
+			windowWidth := ssimWindow
+			if wx+windowWidth > width {
+				windowWidth = width - wx
+			}
+
+			x0 := bounds.Min.X + wx
+			y0 := bounds.Min.Y + wy
+			for channel, weight := range ssimChannelWeights {
+				weightedSum += weight * windowSSIM(original, candidate, x0, y0, windowWidth, windowHeight, channel)
+			}
+			windowCount++
+		}
+	}
+
+	if windowCount == 0 {
+		return 1
+	}
+	return weightedSum / windowCount
+}
+
+// windowSSIM computes the SSIM of a single w x h window starting at
+// (x0, y0) for one color channel (0=red, 1=green, 2=blue).
+func windowSSIM(original, candidate image.Image, x0, y0, w, h, channel int) float64 {
+	n := float64(w * h)
+
+	var sumOriginal, sumCandidate float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sumOriginal += channelAt(original, x0+x, y0+y, channel)
+			sumCandidate += channelAt(candidate, x0+x, y0+y, channel)
+		}
+	}
+	meanOriginal := sumOriginal / n
+	meanCandidate := sumCandidate / n
+
+	var varOriginal, varCandidate, covariance float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			deltaOriginal := channelAt(original, x0+x, y0+y, channel) - meanOriginal
+			deltaCandidate := channelAt(candidate, x0+x, y0+y, channel) - meanCandidate
+			varOriginal += deltaOriginal * deltaOriginal
+			varCandidate += deltaCandidate * deltaCandidate
+			covariance += deltaOriginal * deltaCandidate
+		}
+	}
+	varOriginal /= n
+	varCandidate /= n
+	covariance /= n
+
+	numerator := (2*meanOriginal*meanCandidate + ssimC1) * (2*covariance + ssimC2)
+	denominator := (meanOriginal*meanOriginal + meanCandidate*meanCandidate + ssimC1) * (varOriginal + varCandidate + ssimC2)
+	return numerator / denominator
+}
+
+func channelAt(img image.Image, x, y, channel int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	switch channel {
+	case 0:
+		return float64(r >> 8)
+	case 1:
+		return float64(g >> 8)
+	default:
+		return float64(b >> 8)
+	}
+}
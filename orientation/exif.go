@@ -0,0 +1,105 @@
+package orientation
+
+import "encoding/binary"
+
+// exifHeader is the marker that identifies an APP1 segment as carrying
+// EXIF metadata, immediately preceding the TIFF header.
+const exifHeader = "Exif\x00\x00"
+
+// Read scans raw JPEG file bytes for an EXIF Orientation tag (0x0112) and
+// returns it. It returns Unspecified if the bytes don't carry a JPEG APP1
+// EXIF segment, or the segment has no Orientation tag.
+func Read(data []byte) Orientation {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return Unspecified
+	}
+	tiff := findEXIF(data)
+	if tiff == nil {
+		return Unspecified
+	}
+	return readOrientationTag(tiff)
+}
+
+// findEXIF walks the JPEG marker segments in data and returns the TIFF
+// payload of the first APP1 segment tagged as EXIF, or nil if there is
+// none.
+func findEXIF(data []byte) []byte {
+	i := 0
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			i++
+			continue
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// markers without a payload
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// start of scan or end of image: no more metadata follows
+			return nil
+		}
+
+		if i+4 > len(data) {
+			return nil
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if length < 2 || i+2+length > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) > len(exifHeader) && string(segment[:len(exifHeader)]) == exifHeader {
+			return segment[len(exifHeader):]
+		}
+		i += 2 + length
+	}
+	return nil
+}
+
+// orientationTag is the EXIF tag ID for image orientation.
+const orientationTag = 0x0112
+
+// readOrientationTag parses a TIFF-structured EXIF payload and returns its
+// Orientation tag, or Unspecified if the payload is malformed or has none.
+func readOrientationTag(tiff []byte) Orientation {
+	if len(tiff) < 8 {
+		return Unspecified
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return Unspecified
+	}
+	if order.Uint16(tiff[2:4]) != 0x002A {
+		return Unspecified
+	}
+
+	ifdOffset := order.Uint32(tiff[4:8])
+	if int(ifdOffset)+2 > len(tiff) {
+		return Unspecified
+	}
+	entryCount := int(order.Uint16(tiff[ifdOffset:]))
+	entries := int(ifdOffset) + 2
+
+	for i := 0; i < entryCount; i++ {
+		entry := entries + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		value := order.Uint16(tiff[entry+8:])
+		if value < 1 || value > 8 {
+			return Unspecified
+		}
+		return Orientation(value)
+	}
+	return Unspecified
+}
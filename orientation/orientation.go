@@ -0,0 +1,72 @@
+// Package orientation reads the EXIF Orientation tag from raw JPEG bytes
+// and applies the flip/rotate it describes, so that images whose camera
+// held them sideways or upside-down are normalized before further
+// processing.
+package orientation
+
+import "image"
+
+// Orientation is an EXIF Orientation tag value (0x0112), describing how a
+// decoded image must be flipped and/or rotated to display upright.
+type Orientation int
+
+// The EXIF Orientation values, in the order the standard defines them.
+const (
+	// Unspecified means no (or no valid) Orientation tag was found; Apply
+	// leaves the image unchanged.
+	Unspecified Orientation = 0
+
+	Normal         Orientation = 1
+	FlipHorizontal Orientation = 2
+	Rotate180      Orientation = 3
+	FlipVertical   Orientation = 4
+	Transpose      Orientation = 5
+	Rotate90       Orientation = 6 // rotate 90 degrees clockwise
+	Transverse     Orientation = 7
+	Rotate270      Orientation = 8 // rotate 270 degrees clockwise
+)
+
+// Apply returns img flipped and/or rotated so that it displays upright,
+// per o's EXIF Orientation convention. Unspecified and Normal return img
+// unchanged.
+func Apply(img image.Image, o Orientation) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var dstW, dstH int
+	var source func(dx, dy int) (int, int)
+	switch o {
+	case FlipHorizontal:
+		dstW, dstH = w, h
+		source = func(dx, dy int) (int, int) { return w - 1 - dx, dy }
+	case Rotate180:
+		dstW, dstH = w, h
+		source = func(dx, dy int) (int, int) { return w - 1 - dx, h - 1 - dy }
+	case FlipVertical:
+		dstW, dstH = w, h
+		source = func(dx, dy int) (int, int) { return dx, h - 1 - dy }
+	case Transpose:
+		dstW, dstH = h, w
+		source = func(dx, dy int) (int, int) { return dy, dx }
+	case Rotate90:
+		dstW, dstH = h, w
+		source = func(dx, dy int) (int, int) { return dy, h - 1 - dx }
+	case Transverse:
+		dstW, dstH = h, w
+		source = func(dx, dy int) (int, int) { return w - 1 - dy, h - 1 - dx }
+	case Rotate270:
+		dstW, dstH = h, w
+		source = func(dx, dy int) (int, int) { return w - 1 - dy, dx }
+	default:
+		return img
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for dy := 0; dy < dstH; dy++ {
+		for dx := 0; dx < dstW; dx++ {
+			sx, sy := source(dx, dy)
+			out.Set(dx, dy, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return out
+}
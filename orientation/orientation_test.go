@@ -0,0 +1,203 @@
+package orientation
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// buildExifJPEG returns minimal JPEG bytes containing a single APP1/EXIF
+// segment with one IFD entry: the Orientation tag set to value.
+func buildExifJPEG(bigEndian bool, value uint16) []byte {
+	put16 := func(b []byte, v uint16) {
+		if bigEndian {
+			b[0], b[1] = byte(v>>8), byte(v)
+		} else {
+			b[0], b[1] = byte(v), byte(v>>8)
+		}
+	}
+	put32 := func(b []byte, v uint32) {
+		if bigEndian {
+			b[0], b[1], b[2], b[3] = byte(v>>24), byte(v>>16), byte(v>>8), byte(v)
+		} else {
+			b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		}
+	}
+
+	tiff := make([]byte, 26)
+	if bigEndian {
+		copy(tiff[0:2], "MM")
+	} else {
+		copy(tiff[0:2], "II")
+	}
+	put16(tiff[2:4], 0x002A)
+	put32(tiff[4:8], 8)  // IFD offset
+	put16(tiff[8:10], 1) // entry count
+	put16(tiff[10:12], orientationTag)
+	put16(tiff[12:14], 3) // type SHORT
+	put32(tiff[14:18], 1) // count
+	put16(tiff[18:20], value)
+	put32(tiff[22:26], 0) // next IFD offset
+
+	payload := append([]byte(exifHeader), tiff...)
+
+	marker := make([]byte, 4+len(payload))
+	marker[0], marker[1] = 0xFF, 0xE1
+	segmentLength := uint16(len(payload) + 2)
+	marker[2], marker[3] = byte(segmentLength>>8), byte(segmentLength) // segment length is always big-endian
+	copy(marker[4:], payload)
+
+	data := []byte{0xFF, 0xD8}
+	data = append(data, marker...)
+	data = append(data, 0xFF, 0xD9)
+	return data
+}
+
+func TestReadOrientation(t *testing.T) {
+	for o := Orientation(1); o <= 8; o++ {
+		for _, bigEndian := range [...]bool{false, true} {
+			data := buildExifJPEG(bigEndian, uint16(o))
+			if got := Read(data); got != o {
+				t.Errorf("Read (bigEndian=%v, orientation=%d): got %d, want %d", bigEndian, o, got, o)
+			}
+		}
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	valid := buildExifJPEG(false, 6)
+
+	cases := map[string][]byte{
+		"empty":            nil,
+		"not a JPEG":       append([]byte{0x89, 'P', 'N', 'G'}, valid...),
+		"truncated at SOI": {0xFF},
+		"no APP1 segment":  {0xFF, 0xD8, 0xFF, 0xD9},
+	}
+	for name, data := range cases {
+		if got := Read(data); got != Unspecified {
+			t.Errorf("Read(%s): got %d, want Unspecified", name, got)
+		}
+	}
+
+	// Wrong magic string in place of "Exif\x00\x00" (data[6:12]).
+	badHeader := append([]byte(nil), valid...)
+	copy(badHeader[6:12], "XXXX\x00\x00")
+	if got := Read(badHeader); got != Unspecified {
+		t.Errorf("Read(bad exif header): got %d, want Unspecified", got)
+	}
+
+	// Corrupt byte-order marker in the TIFF header (data[12:14]).
+	badOrder := append([]byte(nil), valid...)
+	copy(badOrder[12:14], "zz")
+	if got := Read(badOrder); got != Unspecified {
+		t.Errorf("Read(bad byte order): got %d, want Unspecified", got)
+	}
+
+	// Corrupt TIFF magic number, 0x002A (data[14:16]).
+	badMagic := append([]byte(nil), valid...)
+	badMagic[14], badMagic[15] = 0, 0
+	if got := Read(badMagic); got != Unspecified {
+		t.Errorf("Read(bad TIFF magic): got %d, want Unspecified", got)
+	}
+
+	// No Orientation entry in the IFD (entry count at data[20:22]).
+	noTag := buildExifJPEG(false, 6)
+	noTag[20], noTag[21] = 0, 0
+	if got := Read(noTag); got != Unspecified {
+		t.Errorf("Read(no orientation tag): got %d, want Unspecified", got)
+	}
+
+	// Orientation value out of the valid 1-8 range.
+	outOfRange := buildExifJPEG(false, 9)
+	if got := Read(outOfRange); got != Unspecified {
+		t.Errorf("Read(out-of-range orientation): got %d, want Unspecified", got)
+	}
+
+	// Segment truncated partway through the IFD.
+	truncated := valid[:len(valid)-10]
+	if got := Read(truncated); got != Unspecified {
+		t.Errorf("Read(truncated IFD): got %d, want Unspecified", got)
+	}
+}
+
+// corner colors for an asymmetric (width != height) test image, so that a
+// transform that mixes up rows and columns, or rotates the wrong way,
+// shows up as a mismatched pixel rather than cancelling out.
+var (
+	topLeft     = color.NRGBA{255, 0, 0, 255}
+	topRight    = color.NRGBA{0, 255, 0, 255}
+	bottomLeft  = color.NRGBA{0, 0, 255, 255}
+	bottomRight = color.NRGBA{255, 255, 0, 255}
+)
+
+// cornerImage returns a w x h image (w != h) with a distinct color in
+// each corner.
+func cornerImage(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{128, 128, 128, 255})
+		}
+	}
+	img.Set(0, 0, topLeft)
+	img.Set(w-1, 0, topRight)
+	img.Set(0, h-1, bottomLeft)
+	img.Set(w-1, h-1, bottomRight)
+	return img
+}
+
+func TestApply(t *testing.T) {
+	const w, h = 3, 2
+
+	// Expected corner colors (topLeft, topRight, bottomLeft, bottomRight)
+	// of Apply's output, and whether its dimensions are swapped, derived
+	// independently from the geometric definition of each orientation
+	// rather than from orientation.go's implementation.
+	cases := []struct {
+		o              Orientation
+		swapped        bool
+		tl, tr, bl, br color.NRGBA
+	}{
+		{Unspecified, false, topLeft, topRight, bottomLeft, bottomRight},
+		{Normal, false, topLeft, topRight, bottomLeft, bottomRight},
+		{FlipHorizontal, false, topRight, topLeft, bottomRight, bottomLeft},
+		{Rotate180, false, bottomRight, bottomLeft, topRight, topLeft},
+		{FlipVertical, false, bottomLeft, bottomRight, topLeft, topRight},
+		{Transpose, true, topLeft, bottomLeft, topRight, bottomRight},
+		{Rotate90, true, bottomLeft, topLeft, bottomRight, topRight},
+		{Transverse, true, bottomRight, topRight, bottomLeft, topLeft},
+		{Rotate270, true, topRight, bottomRight, topLeft, bottomLeft},
+	}
+
+	for _, c := range cases {
+		img := cornerImage(w, h)
+		out := Apply(img, c.o)
+
+		wantW, wantH := w, h
+		if c.swapped {
+			wantW, wantH = h, w
+		}
+		bounds := out.Bounds()
+		if bounds.Dx() != wantW || bounds.Dy() != wantH {
+			t.Errorf("Apply(o=%d): bounds %v, want %dx%d", c.o, bounds, wantW, wantH)
+			continue
+		}
+
+		at := func(x, y int) color.NRGBA {
+			r, g, b, a := out.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			return color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+		}
+		if got := at(0, 0); got != c.tl {
+			t.Errorf("Apply(o=%d): top-left = %v, want %v", c.o, got, c.tl)
+		}
+		if got := at(wantW-1, 0); got != c.tr {
+			t.Errorf("Apply(o=%d): top-right = %v, want %v", c.o, got, c.tr)
+		}
+		if got := at(0, wantH-1); got != c.bl {
+			t.Errorf("Apply(o=%d): bottom-left = %v, want %v", c.o, got, c.bl)
+		}
+		if got := at(wantW-1, wantH-1); got != c.br {
+			t.Errorf("Apply(o=%d): bottom-right = %v, want %v", c.o, got, c.br)
+		}
+	}
+}